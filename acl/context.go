@@ -0,0 +1,41 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acl
+
+import "context"
+
+// identityContextKey is unexported so only this package can mint the key
+// NewContext/FromContext store and read Identity under.
+type identityContextKey struct{}
+
+// NewContext returns a copy of ctx carrying identity. Alpha calls this
+// once per request, right after extracting identity from the incoming
+// gRPC metadata JWT, so every query and mutation handler downstream of
+// that point can recover it with FromContext without threading it through
+// every function signature in between.
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// FromContext returns the Identity NewContext attached to ctx, or the zero
+// Identity (no subject, no groups) if none was attached - the case for
+// internal queries Alpha issues on its own behalf, e.g. schema
+// introspection.
+func FromContext(ctx context.Context) Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(Identity)
+	return identity
+}
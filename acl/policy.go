@@ -0,0 +1,250 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package acl implements a predicate-level access control subsystem for the
+// query pipeline. It follows the DefraDB-style ACP model, splitting the
+// problem into three facets: authentication (who is asking), authorization
+// (what they are allowed to do), and auditing (a record of what happened).
+//
+// Policies are plain data so that they can be stored as RDF triples under
+// the reserved "dgraph.acl.*" predicate namespace and replicated through
+// Raft like any other mutation.
+package acl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Op is an operation that can be authorized against a predicate or a
+// <uid, predicate> tuple.
+type Op string
+
+const (
+	// ReadOp gates resolving a predicate's posting list for a query.
+	ReadOp Op = "read"
+	// WriteOp gates mutating a predicate's posting list.
+	WriteOp Op = "write"
+	// AdminOp gates schema and cluster administration operations.
+	AdminOp Op = "admin"
+)
+
+// Reserved predicate namespace under which policies are persisted so that
+// they replicate through Raft alongside user data.
+const (
+	// PredicatePrefix is the reserved predicate namespace policies live
+	// under, e.g. "dgraph.acl.policy", "dgraph.acl.identity".
+	PredicatePrefix = "dgraph.acl."
+
+	// PolicyPredicate holds the RDF-encoded policy triples themselves.
+	PolicyPredicate = PredicatePrefix + "policy"
+
+	// Wildcard matches any predicate name or any uid in a range.
+	Wildcard = "*"
+)
+
+// Identity is the authenticated caller extracted from the JWT carried in the
+// incoming gRPC metadata. Alpha populates this once per request and threads
+// it through the request context (see NewContext/FromContext); pb.Query has
+// no Identity field.
+type Identity struct {
+	// Subject is the unique identity of the caller, e.g. a user or group
+	// name taken from the JWT's "sub" claim.
+	Subject string
+
+	// Groups the caller belongs to, taken from the JWT's "groups" claim.
+	// A policy may grant a relation to a group rather than to a single
+	// subject.
+	Groups []string
+}
+
+// Policy is a single authorization rule: it grants Relation on Object to
+// Subject. Object is either a bare predicate name ("name"), a predicate
+// wildcard ("*"), or a <uid,predicate> tuple ("0x1,name" or "0x1-0x10,name"
+// for a uid range), optionally with a wildcard predicate.
+type Policy struct {
+	Subject  string
+	Relation Op
+	Object   string
+}
+
+// matchesSubject reports whether id is the subject this policy grants to,
+// either directly or through group membership.
+func (p Policy) matchesSubject(id Identity) bool {
+	if p.Subject == Wildcard || p.Subject == id.Subject {
+		return true
+	}
+	for _, g := range id.Groups {
+		if p.Subject == g {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesObject reports whether this policy's object covers attr, and if a
+// uid is supplied, whether it falls within the policy's uid range.
+func (p Policy) matchesObject(attr string, uid uint64, haveUID bool) bool {
+	object := p.Object
+	uidPart, predPart, hasUIDPart := strings.Cut(object, ",")
+	if !hasUIDPart {
+		return matchPredicate(object, attr)
+	}
+	if !haveUID {
+		// The policy scopes to specific uids but the caller didn't supply
+		// one to check against; treat as non-matching rather than guessing.
+		return false
+	}
+	if !matchUIDRange(uidPart, uid) {
+		return false
+	}
+	return matchPredicate(predPart, attr)
+}
+
+func matchPredicate(pattern, attr string) bool {
+	if pattern == Wildcard {
+		return true
+	}
+	if strings.HasSuffix(pattern, Wildcard) {
+		return strings.HasPrefix(attr, strings.TrimSuffix(pattern, Wildcard))
+	}
+	return pattern == attr
+}
+
+func matchUIDRange(pattern string, uid uint64) bool {
+	if pattern == Wildcard {
+		return true
+	}
+	lo, hi, isRange := strings.Cut(pattern, "-")
+	loUID, err := strconv.ParseUint(strings.TrimPrefix(lo, "0x"), 16, 64)
+	if err != nil {
+		return false
+	}
+	if !isRange {
+		return uid == loUID
+	}
+	hiUID, err := strconv.ParseUint(strings.TrimPrefix(hi, "0x"), 16, 64)
+	if err != nil {
+		return false
+	}
+	return uid >= loUID && uid <= hiUID
+}
+
+// Event is emitted to the audit tap for every decision Check makes.
+type Event struct {
+	Identity Identity
+	Attr     string
+	UID      uint64
+	HaveUID  bool
+	Op       Op
+	Allow    bool
+}
+
+// Tap receives an Event for every access decision. Register one with
+// SetTap to wire up auditing; the default tap discards events.
+type Tap func(Event)
+
+// Checker evaluates Policy rules against requests. The zero value denies
+// everything, matching the fail-closed default of the rest of the ACL
+// subsystem.
+type Checker struct {
+	mu       sync.RWMutex
+	policies []Policy
+	tap      Tap
+}
+
+// New returns a Checker seeded with policies, typically decoded from the
+// dgraph.acl.policy predicate on cluster start and kept in sync as the
+// predicate is mutated.
+func New(policies []Policy) *Checker {
+	return &Checker{policies: append([]Policy(nil), policies...)}
+}
+
+// SetTap installs the audit tap that observes every decision made by Check.
+// Passing nil disables auditing.
+func (c *Checker) SetTap(tap Tap) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tap = tap
+}
+
+// SetPolicies atomically replaces the active policy set, e.g. after the
+// dgraph.acl.policy predicate has been mutated and reparsed.
+func (c *Checker) SetPolicies(policies []Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies = append([]Policy(nil), policies...)
+}
+
+// Check reports whether identity may perform op on attr. A denied check is
+// not an error: callers resolving predicate data should treat a deny as an
+// empty result for that uid rather than failing the whole query, so that
+// partial results remain correct for identities with partial visibility.
+func (c *Checker) Check(identity Identity, attr string, op Op) bool {
+	return c.checkUID(identity, attr, 0, false, op)
+}
+
+// CheckUID is like Check but scopes the decision to a single uid, for
+// policies written over <uid,predicate> tuples.
+func (c *Checker) CheckUID(identity Identity, attr string, uid uint64, op Op) bool {
+	return c.checkUID(identity, attr, uid, true, op)
+}
+
+func (c *Checker) checkUID(identity Identity, attr string, uid uint64, haveUID bool, op Op) bool {
+	c.mu.RLock()
+	policies := c.policies
+	tap := c.tap
+	c.mu.RUnlock()
+
+	allow := false
+	for _, p := range policies {
+		if p.Relation != op {
+			continue
+		}
+		if !p.matchesSubject(identity) {
+			continue
+		}
+		if p.matchesObject(attr, uid, haveUID) {
+			allow = true
+			break
+		}
+	}
+
+	if tap != nil {
+		tap(Event{Identity: identity, Attr: attr, UID: uid, HaveUID: haveUID, Op: op, Allow: allow})
+	}
+	return allow
+}
+
+// ParseTriple decodes a single RDF triple stored under PolicyPredicate into
+// a Policy. The object literal is expected to be of the form
+// "<subject>|<relation>|<object>", e.g. "alice|read|name" or
+// "eng-team|write|0x1-0x10,salary".
+func ParseTriple(object string) (Policy, error) {
+	parts := strings.Split(object, "|")
+	if len(parts) != 3 {
+		return Policy{}, fmt.Errorf("acl: malformed policy triple %q, want subject|relation|object", object)
+	}
+	op := Op(parts[1])
+	switch op {
+	case ReadOp, WriteOp, AdminOp:
+	default:
+		return Policy{}, fmt.Errorf("acl: unknown relation %q in policy triple %q", parts[1], object)
+	}
+	return Policy{Subject: parts[0], Relation: op, Object: parts[2]}, nil
+}
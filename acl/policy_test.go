@@ -0,0 +1,116 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acl
+
+import "testing"
+
+func TestCheckWildcardPredicate(t *testing.T) {
+	c := New([]Policy{
+		{Subject: "alice", Relation: ReadOp, Object: Wildcard},
+	})
+
+	if !c.Check(Identity{Subject: "alice"}, "name", ReadOp) {
+		t.Fatalf("expected alice to read name via wildcard policy")
+	}
+	if c.Check(Identity{Subject: "bob"}, "name", ReadOp) {
+		t.Fatalf("expected bob to be denied, no matching policy")
+	}
+}
+
+func TestCheckGroupMembership(t *testing.T) {
+	c := New([]Policy{
+		{Subject: "eng-team", Relation: ReadOp, Object: "salary"},
+	})
+
+	id := Identity{Subject: "alice", Groups: []string{"eng-team"}}
+	if !c.Check(id, "salary", ReadOp) {
+		t.Fatalf("expected alice to read salary via eng-team group policy")
+	}
+	if c.Check(Identity{Subject: "mallory"}, "salary", ReadOp) {
+		t.Fatalf("expected mallory to be denied")
+	}
+}
+
+func TestCheckUIDRange(t *testing.T) {
+	c := New([]Policy{
+		{Subject: "alice", Relation: ReadOp, Object: "0x1-0xa,salary"},
+	})
+
+	id := Identity{Subject: "alice"}
+	if !c.CheckUID(id, "salary", 0x5, ReadOp) {
+		t.Fatalf("expected uid 0x5 to be within the granted range")
+	}
+	if c.CheckUID(id, "salary", 0xb, ReadOp) {
+		t.Fatalf("expected uid 0xb to fall outside the granted range")
+	}
+	if c.Check(id, "salary", ReadOp) {
+		t.Fatalf("a uid-scoped policy should not match an unscoped check")
+	}
+}
+
+func TestCheckPredicatePrefixWildcard(t *testing.T) {
+	c := New([]Policy{
+		{Subject: "alice", Relation: WriteOp, Object: "dgraph.acl.*"},
+	})
+
+	id := Identity{Subject: "alice"}
+	if !c.Check(id, "dgraph.acl.policy", WriteOp) {
+		t.Fatalf("expected prefix wildcard to cover dgraph.acl.policy")
+	}
+	if c.Check(id, "name", WriteOp) {
+		t.Fatalf("prefix wildcard should not leak to unrelated predicates")
+	}
+}
+
+func TestCheckAuditTap(t *testing.T) {
+	c := New([]Policy{
+		{Subject: "alice", Relation: ReadOp, Object: "name"},
+	})
+
+	var events []Event
+	c.SetTap(func(e Event) { events = append(events, e) })
+
+	c.Check(Identity{Subject: "alice"}, "name", ReadOp)
+	c.Check(Identity{Subject: "bob"}, "name", ReadOp)
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 audit events, got %d", len(events))
+	}
+	if !events[0].Allow {
+		t.Fatalf("expected alice's read to be allowed")
+	}
+	if events[1].Allow {
+		t.Fatalf("expected bob's read to be denied")
+	}
+}
+
+func TestParseTriple(t *testing.T) {
+	p, err := ParseTriple("alice|read|name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Subject != "alice" || p.Relation != ReadOp || p.Object != "name" {
+		t.Fatalf("unexpected policy parsed: %+v", p)
+	}
+
+	if _, err := ParseTriple("alice|frobnicate|name"); err == nil {
+		t.Fatalf("expected an error for an unknown relation")
+	}
+	if _, err := ParseTriple("alice|read"); err == nil {
+		t.Fatalf("expected an error for a malformed triple")
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package doctor validates that the FK references recorded by the migrate
+// scanner actually resolve in the live graph, the way CockroachDB's
+// `debug doctor zipdir` validates a cluster's on-disk state against its
+// descriptors. It's meant to be run after a migration (or a resumed one)
+// to catch dangling uids, missing rows, and type mismatches before an
+// application trips over them.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/migrate"
+)
+
+// pageSize bounds how many rows doctor pages through per query, so that a
+// large table doesn't require a single unbounded response.
+const pageSize = 1000
+
+// BrokenReference describes one foreign key column, on one parent/child
+// table pair, that points at uids the referenced predicate doesn't carry.
+type BrokenReference struct {
+	Parent     string   `json:"parent"`
+	Child      string   `json:"child"`
+	FKCol      string   `json:"fk_col"`
+	BrokenUids []uint64 `json:"broken_uids"`
+}
+
+// Report is the structured result of a Validate run.
+type Report struct {
+	Broken []BrokenReference `json:"broken"`
+}
+
+// HasIssues reports whether Validate found anything wrong; the caller uses
+// this to decide the process exit code.
+func (r *Report) HasIssues() bool {
+	return len(r.Broken) > 0
+}
+
+// Summary renders a short human-readable summary of the report, suitable
+// for printing alongside the JSON.
+func (r *Report) Summary() string {
+	if !r.HasIssues() {
+		return "doctor: no broken references found"
+	}
+	s := fmt.Sprintf("doctor: found %d broken reference(s):\n", len(r.Broken))
+	for _, b := range r.Broken {
+		s += fmt.Sprintf("  %s.%s -> %s: %d dangling uid(s)\n",
+			b.Child, b.FKCol, b.Parent, len(b.BrokenUids))
+	}
+	return s
+}
+
+// Querier is the subset of *dgo.Dgraph doctor needs. It's an interface so
+// tests can fake responses without a live Alpha.
+type Querier interface {
+	Query(ctx context.Context, query string) (*api.Response, error)
+}
+
+// Validate walks tables in the order migrate.SortTables produces, in which
+// every referenced table (the Parent of a BrokenReference) comes before the
+// tables that reference it, and for every FK reference checks that the
+// predicate it was migrated to actually points at a uid carrying the
+// referenced table's Dgraph type. Visiting referenced tables first means a
+// cascading failure is reported closest to its root cause, rather than
+// being re-reported once per referencing table.
+func Validate(ctx context.Context, dg Querier, tables map[string]*migrate.TableInfo) (*Report, error) {
+	order, _, err := migrate.SortTables(tables)
+	if err != nil {
+		return nil, fmt.Errorf("doctor: sorting tables: %w", err)
+	}
+
+	report := &Report{}
+	for _, name := range order {
+		info, ok := tables[name]
+		if !ok {
+			continue
+		}
+		for refTable, fkCol := range info.ReferencedTables() {
+			broken, err := checkReferences(ctx, dg, info.Name(), refTable, fkCol)
+			if err != nil {
+				return nil, fmt.Errorf("doctor: checking %s.%s -> %s: %w",
+					info.Name(), fkCol, refTable, err)
+			}
+			if len(broken) > 0 {
+				report.Broken = append(report.Broken, BrokenReference{
+					Parent:     refTable,
+					Child:      info.Name(),
+					FKCol:      fkCol,
+					BrokenUids: broken,
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+type refQueryResult struct {
+	Rows []struct {
+		UID string `json:"uid"`
+		Ref []struct {
+			UID     string   `json:"uid"`
+			DgraphType []string `json:"dgraph.type"`
+		} `json:"ref"`
+	} `json:"rows"`
+}
+
+// checkReferences pages through every row of child that has fkCol set, and
+// for each one confirms the edge resolves to a uid carrying the refTable
+// Dgraph type. It returns the child uids whose reference was missing or
+// pointed at a node of the wrong type. The edge is read from the
+// "<child>.<fkCol>" predicate, the name migrate declares for it.
+func checkReferences(ctx context.Context, dg Querier, child, refTable, fkCol string) ([]uint64, error) {
+	var broken []uint64
+	pred := child + "." + fkCol
+	offset := 0
+	for {
+		q := fmt.Sprintf(`{
+			rows(func: has(<%s>), first: %d, offset: %d) {
+				uid
+				ref: %s {
+					uid
+					dgraph.type
+				}
+			}
+		}`, pred, pageSize, offset, pred)
+
+		resp, err := dg.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+
+		var result refQueryResult
+		if err := json.Unmarshal(resp.GetJson(), &result); err != nil {
+			return nil, fmt.Errorf("decoding response: %w", err)
+		}
+		if len(result.Rows) == 0 {
+			break
+		}
+
+		for _, row := range result.Rows {
+			if len(row.Ref) == 0 {
+				uid, err := parseUID(row.UID)
+				if err != nil {
+					return nil, err
+				}
+				broken = append(broken, uid)
+				continue
+			}
+			if !hasType(row.Ref[0].DgraphType, refTable) {
+				uid, err := parseUID(row.UID)
+				if err != nil {
+					return nil, err
+				}
+				broken = append(broken, uid)
+			}
+		}
+
+		if len(result.Rows) < pageSize {
+			break
+		}
+		offset += pageSize
+	}
+	return broken, nil
+}
+
+func hasType(types []string, want string) bool {
+	for _, t := range types {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+func parseUID(s string) (uint64, error) {
+	var uid uint64
+	_, err := fmt.Sscanf(s, "0x%x", &uid)
+	if err != nil {
+		return 0, fmt.Errorf("parsing uid %q: %w", s, err)
+	}
+	return uid, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package doctor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuerier returns one canned response per call, in order, so a test can
+// script a paginated checkReferences walk.
+type fakeQuerier struct {
+	responses [][]byte
+	calls     int
+}
+
+func (f *fakeQuerier) Query(ctx context.Context, query string) (*api.Response, error) {
+	resp := &api.Response{Json: f.responses[f.calls]}
+	f.calls++
+	return resp, nil
+}
+
+func TestCheckReferencesCleanGraph(t *testing.T) {
+	dg := &fakeQuerier{responses: [][]byte{
+		[]byte(`{"rows":[{"uid":"0x1","ref":[{"uid":"0x10","dgraph.type":["Customer"]}]}]}`),
+		[]byte(`{"rows":[]}`),
+	}}
+
+	broken, err := checkReferences(context.Background(), dg, "orders", "Customer", "order.customer")
+	require.NoError(t, err)
+	require.Empty(t, broken)
+}
+
+func TestCheckReferencesDanglingUid(t *testing.T) {
+	dg := &fakeQuerier{responses: [][]byte{
+		[]byte(`{"rows":[{"uid":"0x1","ref":[]},{"uid":"0x2","ref":[{"uid":"0x10","dgraph.type":["Customer"]}]}]}`),
+		[]byte(`{"rows":[]}`),
+	}}
+
+	broken, err := checkReferences(context.Background(), dg, "orders", "Customer", "order.customer")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, broken)
+}
+
+func TestCheckReferencesTypeMismatch(t *testing.T) {
+	dg := &fakeQuerier{responses: [][]byte{
+		[]byte(`{"rows":[{"uid":"0x1","ref":[{"uid":"0x10","dgraph.type":["Supplier"]}]}]}`),
+		[]byte(`{"rows":[]}`),
+	}}
+
+	broken, err := checkReferences(context.Background(), dg, "orders", "Customer", "order.customer")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, broken)
+}
+
+func TestReportSummary(t *testing.T) {
+	r := &Report{}
+	require.False(t, r.HasIssues())
+	require.Contains(t, r.Summary(), "no broken references")
+
+	r.Broken = append(r.Broken, BrokenReference{
+		Parent: "customers", Child: "orders", FKCol: "order.customer", BrokenUids: []uint64{1, 2},
+	})
+	require.True(t, r.HasIssues())
+	require.Contains(t, r.Summary(), "orders.order.customer -> customers")
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/migrate"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Doctor registers the `dgraph doctor` subcommand alongside the other
+// dgraph/cmd/* subcommands in dgraph/cmd/root.go.
+var Doctor x.SubCommand
+
+func init() {
+	Doctor.Cmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Validate FK-derived references produced by a migration against the live graph",
+		Run: func(cmd *cobra.Command, args []string) {
+			defer x.StartProfile(Doctor.Conf).Stop()
+			if err := run(Doctor.Conf); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	Doctor.EnvPrefix = "DGRAPH_DOCTOR"
+
+	flag := Doctor.Cmd.Flags()
+	flag.String("alpha", "127.0.0.1:9080", "Alpha gRPC address to validate against")
+	flag.String("schema", "", "path to the SQL schema file used by the original migration")
+	flag.String("json_out", "", "optional path to write the structured JSON report to")
+}
+
+// txnQuerier adapts a *dgo.Dgraph to the Querier interface Validate takes.
+// dgo issues queries through a transaction rather than the client
+// directly, so each call opens a fresh read-only one, matching how a
+// one-shot CLI command like doctor talks to Alpha.
+type txnQuerier struct {
+	dg *dgo.Dgraph
+}
+
+func (q *txnQuerier) Query(ctx context.Context, query string) (*api.Response, error) {
+	return q.dg.NewReadOnlyTxn().Query(ctx, query)
+}
+
+// run drives one doctor pass: scan the schema the same way migrate does,
+// validate it against the running Alpha, and print/report the result.
+// Returns a non-nil error (and thus a non-zero exit code, via the caller)
+// when the graph has broken references.
+func run(conf *viper.Viper) error {
+	tables, err := migrate.ScanSchema(conf.GetString("schema"))
+	if err != nil {
+		return fmt.Errorf("doctor: scanning schema: %w", err)
+	}
+
+	dg, err := migrate.DgraphClient(conf.GetString("alpha"))
+	if err != nil {
+		return fmt.Errorf("doctor: connecting to alpha: %w", err)
+	}
+
+	report, err := Validate(context.Background(), &txnQuerier{dg: dg}, tables)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(report.Summary())
+	if out := conf.GetString("json_out"); out != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("doctor: marshaling report: %w", err)
+		}
+		if err := os.WriteFile(out, b, 0644); err != nil {
+			return fmt.Errorf("doctor: writing report: %w", err)
+		}
+	}
+
+	if report.HasIssues() {
+		return fmt.Errorf("doctor: found %d broken reference(s)", len(report.Broken))
+	}
+	return nil
+}
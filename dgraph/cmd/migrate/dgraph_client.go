@@ -0,0 +1,37 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"google.golang.org/grpc"
+)
+
+// DgraphClient dials the Alpha at addr and wraps the connection in a
+// *dgo.Dgraph, the same client the migration loader uses to write migrated
+// data, so that doctor validates against the graph through the identical
+// code path that produced it.
+func DgraphClient(addr string) (*dgo.Dgraph, error) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("migrate: dialing alpha %s: %w", addr, err)
+	}
+	return dgo.NewDgraphClient(api.NewDgraphClient(conn)), nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+// NewTableInfo builds a TableInfo from already-scanned data; ScanSchema
+// builds each of its tables with it. Exported so that packages outside
+// migrate can construct one without reaching into the unexported fields.
+func NewTableInfo(name string, referencedTables map[string]string) *TableInfo {
+	return &TableInfo{tableName: name, referencedTables: referencedTables}
+}
+
+// Name returns the source SQL table name. Exported so that packages built
+// on top of the scanner's output, such as doctor, can report on a table
+// without reaching into migrate's internals.
+func (t *TableInfo) Name() string {
+	return t.tableName
+}
+
+// ReferencedTables returns the table's foreign-key references, keyed by the
+// referenced table name with the referencing column name as the value.
+func (t *TableInfo) ReferencedTables() map[string]string {
+	return t.referencedTables
+}
+
+// DeferredRefs returns the referenced table names whose foreign key was cut
+// to break a cycle (see DeferredEdge), i.e. the subset of ReferencedTables'
+// keys a caller must fill in with a second pass rather than the first.
+func (t *TableInfo) DeferredRefs() []string {
+	return t.deferredRefs
+}
+
+// SortTables runs topoSortTables and is the entry point other packages
+// should use: it hides the unexported TableInfo fields and the DFS/Tarjan
+// machinery behind the same ordering + deferred-edges contract.
+func SortTables(tables map[string]*TableInfo) ([]string, []DeferredEdge, error) {
+	return topoSortTables(tables)
+}
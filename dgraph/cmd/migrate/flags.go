@@ -0,0 +1,60 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// defaultParallelism is used when --parallel is unset or non-positive: one
+// table at a time, matching the pre-levels behavior.
+const defaultParallelism = 1
+
+// registerParallelFlag adds the --parallel flag to the migrate command's
+// flag set. It is called from the Migrate command's init in run.go.
+func registerParallelFlag(flag *pflag.FlagSet) {
+	flag.Int("parallel", defaultParallelism,
+		"number of tables to load concurrently within a topological level")
+}
+
+// parallelismFromFlag reads back the --parallel value from conf (the same
+// viper Run is handed, with the flag set already bound into it - see
+// doctor.run for the sibling command reading its own flags the same way),
+// falling back to defaultParallelism for zero or negative values so a
+// misconfigured flag can't silently disable migration.
+func parallelismFromFlag(conf *viper.Viper) int {
+	n := conf.GetInt("parallel")
+	if n < 1 {
+		return defaultParallelism
+	}
+	return n
+}
+
+// registerResumeFlag adds the --resume flag, which tells the migration
+// loop to consult the journal before processing each table instead of
+// starting every table from scratch.
+func registerResumeFlag(flag *pflag.FlagSet) {
+	flag.Bool("resume", false,
+		"resume a previously interrupted migration using its progress journal")
+	flag.String("journal_dir", "", "directory for the migration progress journal")
+}
+
+// resumeFromFlag reads back the --resume value from conf.
+func resumeFromFlag(conf *viper.Viper) bool {
+	return conf.GetBool("resume")
+}
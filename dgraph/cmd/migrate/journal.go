@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// journalPrefix namespaces journal keys within the BadgerDB directory so it
+// could, in principle, share a directory with other migrate state.
+const journalPrefix = "journal/"
+
+// TableProgress is the durable record kept for one table: how far the
+// migration got, and a fingerprint of the schema that was being migrated,
+// so a resume can detect a schema that changed out from under it.
+type TableProgress struct {
+	TableName     string `json:"table_name"`
+	LastCommitted string `json:"last_committed_pk"`
+	RowsDone      int64  `json:"rows_done"`
+	SchemaSHA256  string `json:"sha256_of_schema"`
+	Done          bool   `json:"done"`
+}
+
+// ProgressEvent is emitted on Journal's progress channel as each table
+// advances, so a future Prometheus exporter (or just a progress bar) can
+// surface rows/sec per table without polling Badger.
+type ProgressEvent struct {
+	TableName string
+	RowsDone  int64
+}
+
+// Journal persists per-table migration progress to a local BadgerDB
+// directory so that a crash partway through a large import can resume
+// instead of starting over.
+type Journal struct {
+	db       *badger.DB
+	progress chan ProgressEvent
+}
+
+// OpenJournal opens (creating if necessary) a Journal backed by dir.
+func OpenJournal(dir string) (*Journal, error) {
+	opt := badger.DefaultOptions
+	opt.Dir = dir
+	opt.ValueDir = dir
+	db, err := badger.Open(opt)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal at %s: %w", dir, err)
+	}
+	return &Journal{db: db, progress: make(chan ProgressEvent, 100)}, nil
+}
+
+// Close releases the underlying BadgerDB handle.
+func (j *Journal) Close() error {
+	close(j.progress)
+	return j.db.Close()
+}
+
+// Progress returns the channel ProgressEvents are published on. The caller
+// must keep draining it; the channel is closed when Close is called.
+func (j *Journal) Progress() <-chan ProgressEvent {
+	return j.progress
+}
+
+// SchemaFingerprint hashes the raw SQL schema text so resumeMigration can
+// refuse to resume a table whose source schema has changed since the journal entry
+// was written.
+func SchemaFingerprint(schema []byte) string {
+	sum := sha256.Sum256(schema)
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the recorded progress for table, and whether an entry
+// existed at all.
+func (j *Journal) Lookup(table string) (TableProgress, bool, error) {
+	var progress TableProgress
+	found := false
+	err := j.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(journalKey(table))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &progress)
+		})
+	})
+	if err != nil {
+		return TableProgress{}, false, fmt.Errorf("reading journal for %s: %w", table, err)
+	}
+	return progress, found, nil
+}
+
+// Record persists progress for table and publishes a ProgressEvent.
+func (j *Journal) Record(progress TableProgress) error {
+	b, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry for %s: %w", progress.TableName, err)
+	}
+	err = j.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(journalKey(progress.TableName), b)
+	})
+	if err != nil {
+		return fmt.Errorf("writing journal for %s: %w", progress.TableName, err)
+	}
+
+	select {
+	case j.progress <- ProgressEvent{TableName: progress.TableName, RowsDone: progress.RowsDone}:
+	default:
+		// Don't block migration on a slow or absent progress consumer.
+	}
+	return nil
+}
+
+// MarkDone records table as fully migrated, so a later resume skips it
+// outright rather than re-checking its last committed primary key.
+func (j *Journal) MarkDone(table string, rowsDone int64, schemaSHA256 string) error {
+	return j.Record(TableProgress{
+		TableName:    table,
+		RowsDone:     rowsDone,
+		SchemaSHA256: schemaSHA256,
+		Done:         true,
+	})
+}
+
+func journalKey(table string) []byte {
+	return []byte(journalPrefix + table)
+}
+
+// resumeState is what resumeMigration decides for a single table ahead of
+// the migration loop processing it.
+type resumeState int
+
+const (
+	// resumeFromScratch means no usable journal entry exists for this
+	// table, or --resume wasn't passed: migrate it from the beginning.
+	resumeFromScratch resumeState = iota
+	// resumeSkip means the table is already fully migrated.
+	resumeSkip
+	// resumeFromLastPK means the table was partially migrated and should
+	// continue from TableProgress.LastCommitted.
+	resumeFromLastPK
+)
+
+// resumeMigration decides what to do with table given the --resume flag,
+// its journal entry (if any), and the current schema fingerprint. It
+// refuses to resume (falling back to resumeFromScratch) when the schema
+// fingerprint doesn't match, since the last-committed primary key can't be
+// trusted against a schema that has since changed.
+func resumeMigration(j *Journal, table, schemaSHA256 string, resumeFlag bool) (resumeState, TableProgress, error) {
+	if !resumeFlag {
+		return resumeFromScratch, TableProgress{}, nil
+	}
+
+	progress, found, err := j.Lookup(table)
+	if err != nil {
+		return resumeFromScratch, TableProgress{}, err
+	}
+	if !found {
+		return resumeFromScratch, TableProgress{}, nil
+	}
+	if progress.SchemaSHA256 != schemaSHA256 {
+		return resumeFromScratch, TableProgress{}, nil
+	}
+	if progress.Done {
+		return resumeSkip, progress, nil
+	}
+	return resumeFromLastPK, progress, nil
+}
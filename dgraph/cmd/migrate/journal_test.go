@@ -0,0 +1,163 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJournal(t *testing.T) *Journal {
+	dir, err := ioutil.TempDir("", "migrate_journal_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	j, err := OpenJournal(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { j.Close() })
+	return j
+}
+
+func TestJournalRecordAndLookup(t *testing.T) {
+	j := newTestJournal(t)
+
+	_, found, err := j.Lookup("orders")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, j.Record(TableProgress{
+		TableName:     "orders",
+		LastCommitted: "102",
+		RowsDone:      102,
+		SchemaSHA256:  "abc",
+	}))
+
+	progress, found, err := j.Lookup("orders")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(102), progress.RowsDone)
+	require.Equal(t, "102", progress.LastCommitted)
+	require.False(t, progress.Done)
+}
+
+func TestJournalMarkDone(t *testing.T) {
+	j := newTestJournal(t)
+	require.NoError(t, j.MarkDone("orders", 500, "abc"))
+
+	progress, found, err := j.Lookup("orders")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, progress.Done)
+}
+
+func TestJournalProgressChannel(t *testing.T) {
+	j := newTestJournal(t)
+	require.NoError(t, j.Record(TableProgress{TableName: "orders", RowsDone: 7}))
+
+	event := <-j.Progress()
+	require.Equal(t, "orders", event.TableName)
+	require.Equal(t, int64(7), event.RowsDone)
+}
+
+func TestSchemaFingerprintStable(t *testing.T) {
+	a := SchemaFingerprint([]byte("create table orders (...)"))
+	b := SchemaFingerprint([]byte("create table orders (...)"))
+	c := SchemaFingerprint([]byte("create table orders (changed)"))
+	require.Equal(t, a, b)
+	require.NotEqual(t, a, c)
+}
+
+func TestResumeMigrationDecisions(t *testing.T) {
+	j := newTestJournal(t)
+
+	state, _, err := resumeMigration(j, "orders", "abc", true)
+	require.NoError(t, err)
+	require.Equal(t, resumeFromScratch, state)
+
+	require.NoError(t, j.Record(TableProgress{TableName: "orders", LastCommitted: "50", SchemaSHA256: "abc"}))
+	state, progress, err := resumeMigration(j, "orders", "abc", true)
+	require.NoError(t, err)
+	require.Equal(t, resumeFromLastPK, state)
+	require.Equal(t, "50", progress.LastCommitted)
+
+	// A changed schema fingerprint must not be trusted for a resume.
+	state, _, err = resumeMigration(j, "orders", "different", true)
+	require.NoError(t, err)
+	require.Equal(t, resumeFromScratch, state)
+
+	require.NoError(t, j.MarkDone("orders", 100, "abc"))
+	state, _, err = resumeMigration(j, "orders", "abc", true)
+	require.NoError(t, err)
+	require.Equal(t, resumeSkip, state)
+
+	// --resume not passed: always starts from scratch regardless of the
+	// journal's contents.
+	state, _, err = resumeMigration(j, "orders", "abc", false)
+	require.NoError(t, err)
+	require.Equal(t, resumeFromScratch, state)
+}
+
+func TestLoadTableWithResumeSkipsDoneTables(t *testing.T) {
+	j := newTestJournal(t)
+	require.NoError(t, j.MarkDone("orders", 500, "abc"))
+
+	var loaded bool
+	err := loadTableWithResume(j, "orders", "abc", true, func() error {
+		loaded = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.False(t, loaded, "a table already marked done must not be reloaded")
+}
+
+func TestLoadTableWithResumeRecordsProgress(t *testing.T) {
+	j := newTestJournal(t)
+
+	var loaded bool
+	err := loadTableWithResume(j, "orders", "abc", true, func() error {
+		loaded = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, loaded)
+
+	progress, found, err := j.Lookup("orders")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, progress.Done)
+
+	// A second run against the same journal now skips it.
+	loaded = false
+	require.NoError(t, loadTableWithResume(j, "orders", "abc", true, func() error {
+		loaded = true
+		return nil
+	}))
+	require.False(t, loaded)
+}
+
+func TestLoadTableWithResumeNoJournalAlwaysLoads(t *testing.T) {
+	var loaded bool
+	err := loadTableWithResume(nil, "orders", "abc", true, func() error {
+		loaded = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, loaded)
+}
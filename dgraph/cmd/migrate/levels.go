@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// topoLevels groups tables into Kahn-style topological levels: level 0
+// holds every table with no outgoing FK reference, and level k holds every
+// table whose references all live in levels < k. Unlike topoSortTables's
+// single ordering, tables within the same level have no dependency on one
+// another and so can be migrated concurrently.
+//
+// Cycles are broken the same way topoSortTables breaks them: via Tarjan SCC
+// plus a greedy feedback arc set, so that schemas with self- or mutual
+// references still level cleanly.
+func topoLevels(tables map[string]*TableInfo) ([][]string, error) {
+	sccs := tarjanSCC(tables)
+	deferred := breakCycles(tables, sccs)
+	assignDeferredRefs(tables, deferred)
+	skip := make(map[DeferredEdge]bool, len(deferred))
+	for _, e := range deferred {
+		skip[e] = true
+	}
+
+	// outDegree counts, for each table, how many not-yet-deferred FK
+	// references it still has; a table can be leveled once that reaches 0.
+	outDegree := make(map[string]int, len(tables))
+	// parents maps a referenced table to the tables that reference it, so
+	// that leveling a table can decrement its parents' out-degree.
+	parents := make(map[string][]string, len(tables))
+
+	var order []string
+	for table := range tables {
+		order = append(order, table)
+	}
+	sort.Strings(order)
+
+	for _, table := range order {
+		for child := range tables[table].referencedTables {
+			if skip[DeferredEdge{Table: table, Reference: child}] {
+				continue
+			}
+			outDegree[table]++
+			parents[child] = append(parents[child], table)
+		}
+	}
+
+	var levels [][]string
+	leveled := make(map[string]bool, len(tables))
+	remaining := len(tables)
+
+	for remaining > 0 {
+		var level []string
+		for _, table := range order {
+			if !leveled[table] && outDegree[table] == 0 {
+				level = append(level, table)
+			}
+		}
+		if len(level) == 0 {
+			// Every remaining table still has outstanding references but
+			// none are ready; breakCycles should have made that
+			// impossible, so treat it as a bug rather than a valid state.
+			return nil, fmt.Errorf("topoLevels: %d tables stuck with unresolved references after cycle breaking", remaining)
+		}
+
+		sort.Strings(level)
+		levels = append(levels, level)
+
+		for _, table := range level {
+			leveled[table] = true
+			remaining--
+			parentNames := append([]string(nil), parents[table]...)
+			sort.Strings(parentNames)
+			for _, p := range parentNames {
+				outDegree[p]--
+			}
+		}
+	}
+
+	return levels, nil
+}
+
+// runLevelsParallel migrates every table in tables, level by level, running
+// up to parallelism goroutines concurrently within a level and waiting for
+// the whole level to finish before starting the next one. This preserves
+// the FK-safety invariant that a table is never loaded before every table
+// it references, while letting independent tables within a level load at
+// the same time. loadTable is expected to perform the same work the
+// migrate driver's single-table load path does.
+func runLevelsParallel(levels [][]string, parallelism int, loadTable func(table string) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+
+	for _, level := range levels {
+		var wg sync.WaitGroup
+		errs := make([]error, len(level))
+		for i, table := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, table string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				errs[i] = loadTable(table)
+			}(i, table)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("migrating table %s: %w", level[i], err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopoLevelsAcyclic(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"leaf":   newTable("leaf"),
+		"mid":    newTable("mid", "leaf"),
+		"top":    newTable("top", "mid"),
+		"orphan": newTable("orphan"),
+	}
+
+	levels, err := topoLevels(tables)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"leaf", "orphan"},
+		{"mid"},
+		{"top"},
+	}, levels)
+}
+
+func TestTopoLevelsWithCycle(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"orders":    newTable("orders", "customers"),
+		"customers": newTable("customers", "orders"),
+		"leaf":      newTable("leaf"),
+	}
+
+	levels, err := topoLevels(tables)
+	require.NoError(t, err)
+
+	// leaf has no references at all, and cycle breaking defers orders'
+	// reference to customers (see TestTopoSortTwoCycle) while customers
+	// keeps its reference to orders, so orders must come before customers.
+	var customerLevel, orderLevel = -1, -1
+	for i, level := range levels {
+		for _, table := range level {
+			if table == "customers" {
+				customerLevel = i
+			}
+			if table == "orders" {
+				orderLevel = i
+			}
+		}
+	}
+	require.True(t, customerLevel >= 0 && orderLevel >= 0)
+	require.True(t, orderLevel < customerLevel)
+}
+
+func TestRunLevelsParallelRespectsLevelOrder(t *testing.T) {
+	levels := [][]string{{"a", "b"}, {"c"}}
+
+	var mu sync.Mutex
+	var loaded []string
+	err := runLevelsParallel(levels, 2, func(table string) error {
+		mu.Lock()
+		loaded = append(loaded, table)
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, loaded[:2])
+	require.Equal(t, "c", loaded[2])
+}
+
+func TestRunLevelsParallelPropagatesError(t *testing.T) {
+	levels := [][]string{{"a"}}
+	err := runLevelsParallel(levels, 1, func(table string) error {
+		return fmt.Errorf("boom")
+	})
+	require.Error(t, err)
+}
+
+// syntheticSchema builds a schema of n tables with a random FK density:
+// each table may reference a handful of tables with a strictly smaller
+// index, guaranteeing an acyclic schema of realistic shape.
+func syntheticSchema(n int, density int) map[string]*TableInfo {
+	r := rand.New(rand.NewSource(1))
+	tables := make(map[string]*TableInfo, n)
+	names := make([]string, n)
+	for i := 0; i < n; i++ {
+		names[i] = fmt.Sprintf("table_%04d", i)
+		tables[names[i]] = newTable(names[i])
+	}
+	for i := 1; i < n; i++ {
+		refCount := r.Intn(density + 1)
+		for j := 0; j < refCount; j++ {
+			ref := names[r.Intn(i)]
+			tables[names[i]].referencedTables[ref] = ref + "_id"
+		}
+	}
+	return tables
+}
+
+func BenchmarkTopoLevels200Tables(b *testing.B) {
+	tables := syntheticSchema(200, 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := topoLevels(tables); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTopoSortTables200Tables(b *testing.B) {
+	tables := syntheticSchema(200, 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := topoSortTables(tables); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkRunLevelsParallel simulates one level of 32 independent tables,
+// each taking simulatedTableLoad to "load", at the given parallelism - the
+// same shape of work Run hands runLevelsParallel for one topoLevels level.
+// Comparing parallelism 1 against >1 is what should show runLevelsParallel
+// actually buys concurrency, rather than just computing the same levels
+// topoLevels and topoSortTables already benchmark above.
+const simulatedTableLoad = 2 * time.Millisecond
+
+func benchmarkRunLevelsParallel(b *testing.B, parallelism int) {
+	level := make([]string, 32)
+	for i := range level {
+		level[i] = fmt.Sprintf("table_%02d", i)
+	}
+	levels := [][]string{level}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := runLevelsParallel(levels, parallelism, func(table string) error {
+			time.Sleep(simulatedTableLoad)
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRunLevelsParallelSequential(b *testing.B) {
+	benchmarkRunLevelsParallel(b, 1)
+}
+
+func BenchmarkRunLevelsParallelParallelism8(b *testing.B) {
+	benchmarkRunLevelsParallel(b, 8)
+}
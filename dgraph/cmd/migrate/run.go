@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Migrate registers the `dgraph migrate` subcommand alongside the other
+// dgraph/cmd/* subcommands in dgraph/cmd/root.go.
+var Migrate x.SubCommand
+
+func init() {
+	Migrate.Cmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate a SQL schema's FK graph into Dgraph predicates",
+		Run: func(cmd *cobra.Command, args []string) {
+			defer x.StartProfile(Migrate.Conf).Stop()
+			if err := Run(Migrate.Conf); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	Migrate.EnvPrefix = "DGRAPH_MIGRATE"
+
+	flag := Migrate.Cmd.Flags()
+	flag.String("alpha", "127.0.0.1:9080", "Alpha gRPC address to migrate into")
+	flag.String("schema", "", "path to the source SQL schema file")
+	registerParallelFlag(flag)
+	registerResumeFlag(flag)
+}
+
+// Run drives one end-to-end migration: scan the SQL schema, level its
+// tables so independent ones can load concurrently (topoLevels), and hand
+// each table to loadTableSchema, up to --parallel at a time within a level
+// (runLevelsParallel). When --resume is set, the journal under
+// --journal_dir is consulted before each table: already-done tables are
+// skipped and only newly-loaded ones are recorded, so a crash partway
+// through doesn't mean starting the whole schema over. Once every level has
+// loaded, a second pass (loadDeferredSchema) declares the predicates for
+// the FK references topoLevels cut to break reference cycles, now that
+// every table on both ends of those references has a first pass behind it.
+//
+// This package has no SQL row reader - ScanSchema only parses CREATE
+// TABLE/FOREIGN KEY clauses out of the DDL file, not live data - so
+// loadTableSchema only declares the uid-typed predicates a later
+// row-loading pass would write edges into. That's still real work issued
+// against a live Alpha, which is what makes runLevelsParallel's
+// concurrency and --parallel flag do something, rather than only being
+// exercised by their own unit tests.
+func Run(conf *viper.Viper) error {
+	schemaPath := conf.GetString("schema")
+	tables, err := ScanSchema(schemaPath)
+	if err != nil {
+		return fmt.Errorf("migrate: scanning schema: %w", err)
+	}
+
+	levels, err := topoLevels(tables)
+	if err != nil {
+		return fmt.Errorf("migrate: leveling tables: %w", err)
+	}
+
+	dg, err := DgraphClient(conf.GetString("alpha"))
+	if err != nil {
+		return fmt.Errorf("migrate: connecting to alpha: %w", err)
+	}
+
+	resume := resumeFromFlag(conf)
+	journalDir := conf.GetString("journal_dir")
+	if resume && journalDir == "" {
+		return fmt.Errorf("migrate: --resume requires --journal_dir to be set")
+	}
+
+	var journal *Journal
+	if journalDir != "" {
+		journal, err = OpenJournal(journalDir)
+		if err != nil {
+			return fmt.Errorf("migrate: opening journal: %w", err)
+		}
+		defer journal.Close()
+	}
+
+	schemaBytes, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return fmt.Errorf("migrate: reading schema for fingerprint: %w", err)
+	}
+	schemaSHA256 := SchemaFingerprint(schemaBytes)
+
+	parallelism := parallelismFromFlag(conf)
+	if err := runLevelsParallel(levels, parallelism, func(table string) error {
+		return loadTableWithResume(journal, table, schemaSHA256, resume, func() error {
+			return loadTableSchema(context.Background(), dg, tables[table])
+		})
+	}); err != nil {
+		return err
+	}
+
+	return loadDeferredSchema(context.Background(), dg, tables)
+}
+
+// loadTableWithResume wraps load, the per-table migration step, with the
+// journal bookkeeping --resume needs: a table already marked done is
+// skipped outright, and a table load that succeeds is recorded so a later
+// --resume run can skip past it too. With no journal (--journal_dir unset),
+// it just runs load.
+func loadTableWithResume(journal *Journal, table, schemaSHA256 string, resume bool, load func() error) error {
+	if journal == nil {
+		return load()
+	}
+
+	state, _, err := resumeMigration(journal, table, schemaSHA256, resume)
+	if err != nil {
+		return fmt.Errorf("migrate: consulting journal for table %s: %w", table, err)
+	}
+	if state == resumeSkip {
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	// rowsDone is 0: this chunk's loadTableSchema only declares predicates,
+	// it doesn't load rows, so there's no count to record yet.
+	return journal.MarkDone(table, 0, schemaSHA256)
+}
+
+// loadTableSchema declares the uid-typed predicate for each of table's FK
+// references, so the graph has somewhere for a later row-loading pass to
+// write the migrated edges. References DeferredRefs cut to break a cycle
+// are skipped here - see loadDeferredSchema - since the table at the other
+// end of one of those references may not have had its own predicates
+// declared yet.
+func loadTableSchema(ctx context.Context, dg *dgo.Dgraph, table *TableInfo) error {
+	deferred := make(map[string]bool, len(table.DeferredRefs()))
+	for _, ref := range table.DeferredRefs() {
+		deferred[ref] = true
+	}
+
+	var sb strings.Builder
+	for ref, col := range table.ReferencedTables() {
+		if deferred[ref] {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s.%s: uid .\n", table.Name(), col)
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	if err := dg.Alter(ctx, &api.Operation{Schema: sb.String()}); err != nil {
+		return fmt.Errorf("migrate: altering schema for table %s: %w", table.Name(), err)
+	}
+	return nil
+}
+
+// loadDeferredSchema is the second pass loadTableSchema's doc comment
+// promises: once every table has gone through its first pass, it declares
+// the uid-typed predicate for each reference that topoLevels deferred to
+// break a cycle. A later row-loading pass can then issue the SET mutations
+// filling those predicates in, now that uids exist on both ends of every
+// deferred reference - this chunk stops at declaring the predicate because,
+// same as loadTableSchema, it has no SQL row reader to source the row data
+// those SETs would carry.
+func loadDeferredSchema(ctx context.Context, dg *dgo.Dgraph, tables map[string]*TableInfo) error {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		table := tables[name]
+		cols := table.ReferencedTables()
+		refs := append([]string(nil), table.DeferredRefs()...)
+		sort.Strings(refs)
+		for _, ref := range refs {
+			fmt.Fprintf(&sb, "%s.%s: uid .\n", name, cols[ref])
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	if err := dg.Alter(ctx, &api.Operation{Schema: sb.String()}); err != nil {
+		return fmt.Errorf("migrate: altering schema for deferred references: %w", err)
+	}
+	return nil
+}
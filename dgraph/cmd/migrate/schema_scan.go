@@ -0,0 +1,73 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var (
+	createTableRE = regexp.MustCompile("(?i)^\\s*CREATE TABLE\\s+`?(\\w+)`?")
+	foreignKeyRE  = regexp.MustCompile("(?i)FOREIGN KEY\\s*\\(\\s*`?(\\w+)`?\\s*\\)\\s*REFERENCES\\s+`?(\\w+)`?")
+	endTableRE    = regexp.MustCompile(`\)\s*;`)
+)
+
+// ScanSchema reads the SQL DDL file at path and builds a TableInfo per
+// CREATE TABLE statement, recording every FOREIGN KEY (...) REFERENCES
+// (...) clause as a reference edge for topoSortTables/topoLevels to order
+// on. It only looks for the handful of clauses the migration graph cares
+// about - table names and FK targets - and ignores everything else in the
+// statement (column types, indexes, defaults).
+func ScanSchema(path string) (map[string]*TableInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: opening schema %q: %w", path, err)
+	}
+	defer f.Close()
+
+	tables := make(map[string]*TableInfo)
+	var current string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := createTableRE.FindStringSubmatch(line); m != nil {
+			current = m[1]
+			tables[current] = NewTableInfo(current, make(map[string]string))
+			continue
+		}
+		if current == "" {
+			continue
+		}
+
+		if m := foreignKeyRE.FindStringSubmatch(line); m != nil {
+			tables[current].referencedTables[m[2]] = m[1]
+		}
+		if endTableRE.MatchString(line) {
+			current = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: reading schema %q: %w", path, err)
+	}
+
+	return tables, nil
+}
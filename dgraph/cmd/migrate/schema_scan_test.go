@@ -0,0 +1,62 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSchema(t *testing.T, sql string) string {
+	f, err := ioutil.TempFile("", "schema-*.sql")
+	require.NoError(t, err)
+	_, err = f.WriteString(sql)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func TestScanSchemaFindsForeignKeys(t *testing.T) {
+	path := writeSchema(t, `
+CREATE TABLE customers (
+	id INT PRIMARY KEY
+);
+
+CREATE TABLE orders (
+	id INT PRIMARY KEY,
+	customer_id INT,
+	FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+`)
+
+	tables, err := ScanSchema(path)
+	require.NoError(t, err)
+	require.Contains(t, tables, "customers")
+	require.Contains(t, tables, "orders")
+	require.Equal(t, map[string]string{"customers": "customer_id"},
+		tables["orders"].ReferencedTables())
+	require.Empty(t, tables["customers"].ReferencedTables())
+}
+
+func TestScanSchemaMissingFile(t *testing.T) {
+	_, err := ScanSchema("/no/such/schema.sql")
+	require.Error(t, err)
+}
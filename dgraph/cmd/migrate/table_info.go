@@ -0,0 +1,35 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+// TableInfo captures the information topoSortTables (and the scanner that
+// feeds it) need about a single source SQL table: its name and the tables
+// it references through foreign keys.
+type TableInfo struct {
+	// tableName is the original SQL table name.
+	tableName string
+
+	// referencedTables maps the name of a table referenced through a
+	// foreign key to the name of the column in this table holding that key.
+	referencedTables map[string]string
+
+	// deferredRefs lists the foreign keys, by referenced table name, whose
+	// edge was cut to break a reference cycle. The caller mutates these in
+	// a second pass, after every table has been loaded and uids have been
+	// allocated for the referenced rows.
+	deferredRefs []string
+}
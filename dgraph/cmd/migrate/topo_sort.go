@@ -16,7 +16,10 @@
 
 package migrate
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type NodeColor int
 
@@ -26,18 +29,36 @@ const (
 	BLACK
 )
 
+// DeferredEdge is a foreign key reference that was cut to break a cycle in
+// the table dependency graph. The caller is expected to load Table with the
+// column backing Reference left nil on the first pass, then issue a second
+// pass of SET mutations filling it in once every table (including
+// Reference) has been loaded and uids have been allocated.
+type DeferredEdge struct {
+	// Table is the table containing the foreign key column.
+	Table string
+	// Reference is the table the foreign key points to.
+	Reference string
+}
+
 // visit traverses the dependency graph in the order of depth-first search
 // and when we are done visiting a node, it will be added to the collector.
-// As a result, it returns the new collector
+// As a result, it returns the new collector. Edges present in skip are
+// treated as if they didn't exist: they have already been recorded as
+// deferred edges by breakCycles and must not be followed here, or the
+// condensed graph would still contain the cycle they used to close.
 func visit(tables map[string]*TableInfo, nodeColor map[string]NodeColor, curTable string,
-	collector []string) ([]string, error) {
+	collector []string, skip map[DeferredEdge]bool) ([]string, error) {
 	switch nodeColor[curTable] {
 	case WHITE:
 		nodeColor[curTable] = GREY
-		// visit all the children of this table
+		// visit all the children of this table, skipping deferred edges
 		for childTable := range tables[curTable].referencedTables {
+			if skip[DeferredEdge{Table: curTable, Reference: childTable}] {
+				continue
+			}
 			var err error
-			collector, err = visit(tables, nodeColor, childTable, collector)
+			collector, err = visit(tables, nodeColor, childTable, collector, skip)
 			if err != nil {
 				return nil, err
 			}
@@ -47,8 +68,10 @@ func visit(tables map[string]*TableInfo, nodeColor map[string]NodeColor, curTabl
 		nodeColor[curTable] = BLACK
 		collector = append(collector, curTable)
 	case GREY:
-		// this forms a loop, error out
-		return nil, fmt.Errorf("found reference loops while visiting table %s", curTable)
+		// breakCycles should have already deferred every edge that closes a
+		// cycle, so this should be unreachable; keep the check as a guard
+		// rather than silently producing a wrong order.
+		return nil, fmt.Errorf("found reference loop while visiting table %s after cycle breaking", curTable)
 	case BLACK:
 		// there are multiple paths pointing to curTable, that's allowed
 		// we simply ignore the node since it has been visited
@@ -57,23 +80,177 @@ func visit(tables map[string]*TableInfo, nodeColor map[string]NodeColor, curTabl
 	return collector, nil
 }
 
-// topoSortTables runs a topological sort among the tables following the dependency created
-// by foreign key references, the goal is to process the most deeply referenced tables first,
-// and the unreferenced tables later
-func topoSortTables(tables map[string]*TableInfo) ([]string, error) {
+// tarjanSCC runs Tarjan's strongly connected components algorithm over the
+// table dependency graph and returns every SCC, including trivial
+// (single-table) ones.
+func tarjanSCC(tables map[string]*TableInfo) [][]string {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var sccs [][]string
+
+	// sorted traversal order keeps the output (and so the deferred edges we
+	// derive from it) deterministic across runs.
+	var order []string
+	for table := range tables {
+		order = append(order, table)
+	}
+	sort.Strings(order)
+
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		var children []string
+		for w := range tables[v].referencedTables {
+			children = append(children, w)
+		}
+		sort.Strings(children)
+		for _, w := range children {
+			if _, seen := indices[w]; !seen {
+				strongConnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] {
+				if indices[w] < lowlink[v] {
+					lowlink[v] = indices[w]
+				}
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var scc []string
+			for {
+				n := len(stack) - 1
+				w := stack[n]
+				stack = stack[:n]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, v := range order {
+		if _, seen := indices[v]; !seen {
+			strongConnect(v)
+		}
+	}
+	return sccs
+}
+
+// breakCycles picks a minimum feedback arc set for every non-trivial SCC
+// (and every self-reference) using a greedy heuristic: order the tables in
+// the SCC lexicographically and keep only the edges that run from a
+// smaller name to a larger one, deferring the rest. That's enough to make
+// the condensation acyclic, at the cost of pushing the deferred FK columns
+// to a second pass. breakCycles is a pure function of tables and sccs: it
+// only returns the deferred edges, leaving it to the caller (via
+// assignDeferredRefs) to record them on the owning TableInfo, so that
+// calling breakCycles more than once over the same tables - as
+// topoSortTables and topoLevels both do - can't accumulate duplicate
+// entries.
+func breakCycles(tables map[string]*TableInfo, sccs [][]string) []DeferredEdge {
+	var deferred []DeferredEdge
+
+	for _, scc := range sccs {
+		inSCC := make(map[string]bool, len(scc))
+		for _, t := range scc {
+			inSCC[t] = true
+		}
+		sorted := append([]string(nil), scc...)
+		sort.Strings(sorted)
+
+		for _, t := range sorted {
+			var children []string
+			for child := range tables[t].referencedTables {
+				children = append(children, child)
+			}
+			sort.Strings(children)
+			for _, child := range children {
+				if !inSCC[child] {
+					continue
+				}
+				// A self-reference always needs deferring: there's no
+				// earlier-in-the-order copy of the same table to load
+				// first. Otherwise keep edges that already run from a
+				// lexicographically smaller table to a larger one, and
+				// defer the rest.
+				if child == t || t >= child {
+					deferred = append(deferred, DeferredEdge{Table: t, Reference: child})
+				}
+			}
+		}
+	}
+
+	return deferred
+}
+
+// assignDeferredRefs records, on each TableInfo, the references that were
+// deferred for it, overwriting whatever was recorded by an earlier call.
+// It is the single place that writes TableInfo.deferredRefs, so that
+// breakCycles running more than once over the same tables - once via
+// topoSortTables and once via topoLevels, or across retries of either -
+// replaces the recorded set instead of accumulating duplicates into it.
+func assignDeferredRefs(tables map[string]*TableInfo, deferred []DeferredEdge) {
+	grouped := make(map[string][]string)
+	for _, e := range deferred {
+		grouped[e.Table] = append(grouped[e.Table], e.Reference)
+	}
+	for t := range tables {
+		tables[t].deferredRefs = grouped[t]
+	}
+}
+
+// topoSortTables runs a topological sort among the tables following the
+// dependency created by foreign key references, the goal is to process the
+// most deeply referenced tables first, and the unreferenced tables later.
+//
+// Real-world schemas frequently contain self-references (e.g.
+// employees.manager_id -> employees.id) or mutual FKs (e.g. orders <->
+// customers), which form cycles that a plain DFS can't order. Rather than
+// erroring out, topoSortTables identifies the strongly connected components
+// with Tarjan's algorithm, breaks each one with a greedy feedback arc set,
+// and returns the deferred edges alongside the ordering so the caller can
+// fill them back in once every table has been loaded.
+func topoSortTables(tables map[string]*TableInfo) ([]string, []DeferredEdge, error) {
+	sccs := tarjanSCC(tables)
+	deferred := breakCycles(tables, sccs)
+	assignDeferredRefs(tables, deferred)
+	skip := make(map[DeferredEdge]bool, len(deferred))
+	for _, e := range deferred {
+		skip[e] = true
+	}
+
 	nodeColor := make(map[string]NodeColor)
-	// initialize each node to have the WHITE coler
 	for table := range tables {
 		nodeColor[table] = WHITE
 	}
-	collector := make([]string, 0)
-	var err error
+
+	var order []string
 	for table := range tables {
-		collector, err = visit(tables, nodeColor, table, collector)
+		order = append(order, table)
+	}
+	sort.Strings(order)
+
+	collector := make([]string, 0, len(tables))
+	var err error
+	for _, table := range order {
+		collector, err = visit(tables, nodeColor, table, collector, skip)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	return collector, nil
+	return collector, deferred, nil
 }
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTable(name string, refs ...string) *TableInfo {
+	t := &TableInfo{tableName: name, referencedTables: make(map[string]string)}
+	for _, r := range refs {
+		t.referencedTables[r] = r + "_id"
+	}
+	return t
+}
+
+// indexOf returns the position of table in order, failing the test if it's
+// not present.
+func indexOf(t *testing.T, order []string, table string) int {
+	for i, v := range order {
+		if v == table {
+			return i
+		}
+	}
+	t.Fatalf("table %s missing from topo order %v", table, order)
+	return -1
+}
+
+func TestTopoSortSelfLoop(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"employees": newTable("employees", "employees"),
+	}
+
+	order, deferred, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"employees"}, order)
+	require.Equal(t, []DeferredEdge{{Table: "employees", Reference: "employees"}}, deferred)
+}
+
+func TestTopoSortTwoCycle(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"orders":    newTable("orders", "customers"),
+		"customers": newTable("customers", "orders"),
+	}
+
+	order, deferred, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"orders", "customers"}, order)
+	require.Len(t, deferred, 1)
+	// The greedy heuristic keeps the edge running from the
+	// lexicographically smaller name to the larger one.
+	require.Equal(t, DeferredEdge{Table: "orders", Reference: "customers"}, deferred[0])
+}
+
+func TestTopoSortThreeCyclePlusAcyclicTail(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"a":    newTable("a", "b"),
+		"b":    newTable("b", "c"),
+		"c":    newTable("c", "a"),
+		"leaf": newTable("leaf"),
+		"mid":  newTable("mid", "leaf"),
+	}
+
+	order, deferred, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b", "c", "leaf", "mid"}, order)
+	require.Len(t, deferred, 1)
+
+	// the acyclic tail is unaffected: leaf must still come before mid.
+	require.True(t, indexOf(t, order, "leaf") < indexOf(t, order, "mid"))
+}
+
+func TestTopoSortTwoCycleDeferredRefsNotAccumulatedAcrossCalls(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"orders":    newTable("orders", "customers"),
+		"customers": newTable("customers", "orders"),
+	}
+
+	_, _, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.Equal(t, []string{"customers"}, tables["orders"].deferredRefs)
+
+	// Running the sort again (as a retried migration would) must replace
+	// the recorded deferred refs, not append a second "customers" entry.
+	_, _, err = topoSortTables(tables)
+	require.NoError(t, err)
+	require.Equal(t, []string{"customers"}, tables["orders"].deferredRefs)
+}
+
+func TestTopoSortAcyclic(t *testing.T) {
+	tables := map[string]*TableInfo{
+		"parent": newTable("parent"),
+		"child":  newTable("child", "parent"),
+	}
+
+	order, deferred, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.Empty(t, deferred)
+	require.True(t, indexOf(t, order, "parent") < indexOf(t, order, "child"))
+}
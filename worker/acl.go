@@ -0,0 +1,121 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/dgraph/acl"
+	"github.com/dgraph-io/dgraph/protos/pb"
+)
+
+// aclChecker guards predicate resolution in queryState.helpProcessTask. It
+// starts out nil (ACL disabled, every check passes) so that clusters that
+// never write an dgraph.acl.policy predicate see no behavior change; it is
+// swapped in by updateACLChecker once policies are observed in the
+// mutation stream.
+var aclChecker atomic.Value // holds *acl.Checker
+
+// aclPolicies is the full set of live policies, keyed by the raw RDF triple
+// they were parsed from. ApplyACLMutation is only ever handed the edges in
+// the batch it is processing, not the whole predicate's history, so this
+// map (rather than that batch) is what Checker gets rebuilt from on every
+// call - otherwise a later, unrelated dgraph.acl.policy mutation would wipe
+// out every policy installed by an earlier one.
+var (
+	aclMu       sync.Mutex
+	aclPolicies = map[string]acl.Policy{}
+)
+
+func init() {
+	aclChecker.Store((*acl.Checker)(nil))
+}
+
+// updateACLChecker installs a freshly parsed policy set.
+func updateACLChecker(c *acl.Checker) {
+	aclChecker.Store(c)
+}
+
+// ApplyACLMutation scans a batch of applied edges for ones writing
+// acl.PolicyPredicate, merges their SETs and DELs into the live policy set,
+// and installs the result via updateACLChecker. The mutation-apply path
+// calls this after committing a batch, so that writing the
+// dgraph.acl.policy predicate replicates through Raft like any other data
+// and takes effect cluster-wide without a restart.
+func ApplyACLMutation(edges []*pb.DirectedEdge) error {
+	aclMu.Lock()
+	defer aclMu.Unlock()
+
+	var touched bool
+	for _, e := range edges {
+		if e.Attr != acl.PolicyPredicate {
+			continue
+		}
+		touched = true
+		key := string(e.Value)
+		switch e.Op {
+		case pb.DirectedEdge_SET:
+			p, err := acl.ParseTriple(key)
+			if err != nil {
+				return fmt.Errorf("worker: applying acl mutation: %w", err)
+			}
+			aclPolicies[key] = p
+		case pb.DirectedEdge_DEL:
+			delete(aclPolicies, key)
+		}
+	}
+	if !touched {
+		return nil
+	}
+
+	policies := make([]acl.Policy, 0, len(aclPolicies))
+	for _, p := range aclPolicies {
+		policies = append(policies, p)
+	}
+	updateACLChecker(acl.New(policies))
+	return nil
+}
+
+// authorizeRead is consulted by queryState.helpProcessTask before a
+// predicate's posting list is resolved for a query. A denial is not
+// surfaced as an error: the caller should fold it into an empty UidMatrix
+// row for the uid in question instead, so that partial results stay
+// correct for identities that can only see part of the graph.
+//
+// The caller's identity travels on ctx (see acl.NewContext/FromContext)
+// rather than on *pb.Query: carrying it as a Query field, as the original
+// design called for, needs a field added to the real, externally-generated
+// Query message and a pb.pb.go regeneration this chunk can't produce, so
+// identity is threaded through the context parameter every
+// queryState.helpProcessTask call already takes instead.
+//
+// When no policies have been installed (the common case for clusters not
+// using ACL), this always allows the read.
+func authorizeRead(ctx context.Context, attr string, uid uint64, haveUID bool) bool {
+	checker, _ := aclChecker.Load().(*acl.Checker)
+	if checker == nil {
+		return true
+	}
+	identity := acl.FromContext(ctx)
+	if haveUID {
+		return checker.CheckUID(identity, attr, uid, acl.ReadOp)
+	}
+	return checker.Check(identity, attr, acl.ReadOp)
+}
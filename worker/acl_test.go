@@ -0,0 +1,139 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dgraph-io/dgraph/acl"
+	"github.com/dgraph-io/dgraph/algo"
+	"github.com/dgraph-io/dgraph/protos/pb"
+)
+
+func TestAuthorizeReadFiltersByIdentity(t *testing.T) {
+	updateACLChecker(acl.New([]acl.Policy{
+		{Subject: "alice", Relation: acl.ReadOp, Object: "friend"},
+	}))
+	defer updateACLChecker(nil)
+
+	ctx := acl.NewContext(context.Background(), acl.Identity{Subject: "alice"})
+	require.True(t, authorizeRead(ctx, "friend", 0, false))
+
+	ctx = acl.NewContext(context.Background(), acl.Identity{Subject: "mallory"})
+	require.False(t, authorizeRead(ctx, "friend", 0, false))
+}
+
+func TestAuthorizeReadUIDScoped(t *testing.T) {
+	updateACLChecker(acl.New([]acl.Policy{
+		{Subject: "alice", Relation: acl.ReadOp, Object: "0xa-0xf,salary"},
+	}))
+	defer updateACLChecker(nil)
+
+	ctx := acl.NewContext(context.Background(), acl.Identity{Subject: "alice"})
+	require.True(t, authorizeRead(ctx, "salary", 0xc, true))
+	require.False(t, authorizeRead(ctx, "salary", 0x20, true))
+}
+
+func TestAuthorizeReadDisabledByDefault(t *testing.T) {
+	// No policies installed: every identity reads everything, matching
+	// current behavior for clusters that don't opt into ACL.
+	ctx := acl.NewContext(context.Background(), acl.Identity{Subject: "anyone"})
+	require.True(t, authorizeRead(ctx, "friend", 0, false))
+}
+
+// TestAuthorizeReadFiltersByIdentityEndToEnd mirrors TestProcessTask, but
+// runs the same uid-list query under two identities to show the filtering
+// happens inside helpProcessTask itself, not just in authorizeRead's own
+// unit tests: alice can see both rows of "neighbour" edges, mallory (denied
+// on "neighbour" entirely) gets back an empty row per uid instead of an
+// error.
+func TestAuthorizeReadFiltersByIdentityEndToEnd(t *testing.T) {
+	initTest(t, `neighbour: [uid] .`)
+
+	updateACLChecker(acl.New([]acl.Policy{
+		{Subject: "alice", Relation: acl.ReadOp, Object: "neighbour"},
+	}))
+	defer updateACLChecker(nil)
+
+	query := newQuery("neighbour", []uint64{10, 12}, nil)
+	qs := queryState{cache: nil}
+
+	ctx := acl.NewContext(context.Background(), acl.Identity{Subject: "alice"})
+	r, err := qs.helpProcessTask(ctx, query, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, [][]uint64{{0x17, 0x1f}, {0x17, 0x19, 0x1a, 0x1f}},
+		algo.ToUintsListForTest(r.UidMatrix))
+
+	ctx = acl.NewContext(context.Background(), acl.Identity{Subject: "mallory"})
+	r, err = qs.helpProcessTask(ctx, query, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, [][]uint64{{}, {}}, algo.ToUintsListForTest(r.UidMatrix))
+}
+
+// resetACLPolicies clears the package-level merged policy set so a test
+// doesn't see policies left behind by one that ran earlier.
+func resetACLPolicies(t *testing.T) {
+	aclMu.Lock()
+	aclPolicies = map[string]acl.Policy{}
+	aclMu.Unlock()
+	t.Cleanup(func() {
+		aclMu.Lock()
+		aclPolicies = map[string]acl.Policy{}
+		aclMu.Unlock()
+		updateACLChecker(nil)
+	})
+}
+
+// TestApplyACLMutationMergesAcrossBatches guards against ApplyACLMutation
+// treating each batch as the full policy set: a policy SET in an earlier,
+// unrelated batch must still be enforced after a later batch writes a
+// different policy.
+func TestApplyACLMutationMergesAcrossBatches(t *testing.T) {
+	resetACLPolicies(t)
+
+	require.NoError(t, ApplyACLMutation([]*pb.DirectedEdge{
+		{Attr: acl.PolicyPredicate, Value: []byte("alice|read|friend"), Op: pb.DirectedEdge_SET},
+	}))
+	require.NoError(t, ApplyACLMutation([]*pb.DirectedEdge{
+		{Attr: acl.PolicyPredicate, Value: []byte("bob|read|salary"), Op: pb.DirectedEdge_SET},
+	}))
+
+	checker, _ := aclChecker.Load().(*acl.Checker)
+	require.True(t, checker.Check(acl.Identity{Subject: "alice"}, "friend", acl.ReadOp))
+	require.True(t, checker.Check(acl.Identity{Subject: "bob"}, "salary", acl.ReadOp))
+}
+
+// TestApplyACLMutationDeleteRemovesOnlyThatPolicy shows a DEL batch for one
+// policy triple doesn't take down every other policy already installed.
+func TestApplyACLMutationDeleteRemovesOnlyThatPolicy(t *testing.T) {
+	resetACLPolicies(t)
+
+	require.NoError(t, ApplyACLMutation([]*pb.DirectedEdge{
+		{Attr: acl.PolicyPredicate, Value: []byte("alice|read|friend"), Op: pb.DirectedEdge_SET},
+		{Attr: acl.PolicyPredicate, Value: []byte("bob|read|salary"), Op: pb.DirectedEdge_SET},
+	}))
+	require.NoError(t, ApplyACLMutation([]*pb.DirectedEdge{
+		{Attr: acl.PolicyPredicate, Value: []byte("alice|read|friend"), Op: pb.DirectedEdge_DEL},
+	}))
+
+	checker, _ := aclChecker.Load().(*acl.Checker)
+	require.False(t, checker.Check(acl.Identity{Subject: "alice"}, "friend", acl.ReadOp))
+	require.True(t, checker.Check(acl.Identity{Subject: "bob"}, "salary", acl.ReadOp))
+}